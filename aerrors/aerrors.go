@@ -0,0 +1,123 @@
+// Package aerrors implements the error type returned across actor method
+// boundaries. Every actor invocation -- from VMContext.Send on down --
+// returns an ActorError rather than a plain Go error, so that callers up
+// the stack can tell a reverted message (consume gas, apply an exit code
+// to the receipt) apart from a fatal one (abort block/tipset processing
+// entirely, don't touch gas or nonce).
+package aerrors
+
+import "fmt"
+
+// ActorError is the error type returned from actor method invocations.
+// In addition to an error string it carries an exit code to be recorded
+// on the message receipt, and a fatal bit that tells the VM runner
+// whether this error should abort processing outright rather than be
+// recorded as a revert.
+type ActorError interface {
+	error
+
+	// IsFatal returns true if the error should stop all processing of
+	// the current block/tipset; it indicates a problem with this node
+	// rather than a problem with the message being applied.
+	IsFatal() bool
+
+	// RetCode is the exit code to record on the message receipt. It is
+	// meaningless when IsFatal is true.
+	RetCode() uint8
+}
+
+type actorError struct {
+	fatal   bool
+	retCode uint8
+	msg     string
+}
+
+var _ ActorError = (*actorError)(nil)
+
+func (e *actorError) Error() string {
+	return e.msg
+}
+
+func (e *actorError) IsFatal() bool {
+	return e.fatal
+}
+
+func (e *actorError) RetCode() uint8 {
+	return e.retCode
+}
+
+// New creates a new non-fatal ActorError that will cause the message to
+// be reverted with the given exit code.
+func New(code uint8, msg string) ActorError {
+	return &actorError{
+		fatal:   false,
+		retCode: code,
+		msg:     msg,
+	}
+}
+
+// Newf creates a new non-fatal ActorError with a formatted message.
+func Newf(code uint8, format string, args ...interface{}) ActorError {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// Wrap annotates err with msg, preserving its fatality and exit code. It
+// returns nil if err is nil.
+func Wrap(err ActorError, msg string) ActorError {
+	if err == nil {
+		return nil
+	}
+	return &actorError{
+		fatal:   err.IsFatal(),
+		retCode: err.RetCode(),
+		msg:     msg + ": " + err.Error(),
+	}
+}
+
+// Wrapf is Wrap with a formatted message.
+func Wrapf(err ActorError, format string, args ...interface{}) ActorError {
+	return Wrap(err, fmt.Sprintf(format, args...))
+}
+
+// Absorb converts a plain Go error arising from actor code into a
+// non-fatal ActorError that will revert the message with the given exit
+// code. Use this for errors that indicate a problem with the message
+// being applied (bad params, insufficient funds, and so on), not with
+// the node itself. It returns nil if err is nil.
+func Absorb(err error, code uint8, msg string) ActorError {
+	if err == nil {
+		return nil
+	}
+	return &actorError{
+		fatal:   false,
+		retCode: code,
+		msg:     msg + ": " + err.Error(),
+	}
+}
+
+// Absorbf is Absorb with a formatted message.
+func Absorbf(err error, code uint8, format string, args ...interface{}) ActorError {
+	return Absorb(err, code, fmt.Sprintf(format, args...))
+}
+
+// Escalate marks a plain Go error as fatal: the block/tipset currently
+// being processed should be aborted rather than the message reverted.
+// Use this for failures that indicate something is wrong with this
+// node's state or dependencies (storage write failures, missing
+// dependencies) rather than with the message. It returns nil if err is
+// nil.
+func Escalate(err error, msg string) ActorError {
+	if err == nil {
+		return nil
+	}
+	return &actorError{
+		fatal:   true,
+		retCode: 1,
+		msg:     msg + ": " + err.Error(),
+	}
+}
+
+// Escalatef is Escalate with a formatted message.
+func Escalatef(err error, format string, args ...interface{}) ActorError {
+	return Escalate(err, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,89 @@
+package aerrors
+
+import "testing"
+
+func TestNewIsNonFatal(t *testing.T) {
+	err := New(7, "bad params")
+	if err.IsFatal() {
+		t.Fatal("New should produce a non-fatal error")
+	}
+	if err.RetCode() != 7 {
+		t.Fatalf("RetCode() = %d, want 7", err.RetCode())
+	}
+	if err.Error() != "bad params" {
+		t.Fatalf("Error() = %q, want %q", err.Error(), "bad params")
+	}
+}
+
+func TestNewfFormats(t *testing.T) {
+	err := Newf(1, "no method %d", 3)
+	if err.Error() != "no method 3" {
+		t.Fatalf("Error() = %q, want %q", err.Error(), "no method 3")
+	}
+}
+
+func TestAbsorbIsNonFatal(t *testing.T) {
+	cause := errString("disk full")
+	err := Absorb(cause, 9, "failed to unmarshal params")
+	if err.IsFatal() {
+		t.Fatal("Absorb should produce a non-fatal error")
+	}
+	if err.RetCode() != 9 {
+		t.Fatalf("RetCode() = %d, want 9", err.RetCode())
+	}
+	if err.Error() != "failed to unmarshal params: disk full" {
+		t.Fatalf("Error() = %q", err.Error())
+	}
+}
+
+func TestAbsorbNilIsNil(t *testing.T) {
+	if err := Absorb(nil, 1, "msg"); err != nil {
+		t.Fatalf("Absorb(nil, ...) = %v, want nil", err)
+	}
+}
+
+func TestEscalateIsFatal(t *testing.T) {
+	err := Escalate(errString("store closed"), "failed to persist actor")
+	if !err.IsFatal() {
+		t.Fatal("Escalate should produce a fatal error")
+	}
+	if err.RetCode() != 1 {
+		t.Fatalf("RetCode() = %d, want 1", err.RetCode())
+	}
+}
+
+func TestEscalateNilIsNil(t *testing.T) {
+	if err := Escalate(nil, "msg"); err != nil {
+		t.Fatalf("Escalate(nil, ...) = %v, want nil", err)
+	}
+}
+
+func TestWrapPreservesFatalityAndRetCode(t *testing.T) {
+	cause := Newf(4, "insufficient funds")
+	wrapped := Wrap(cause, "transfer failed")
+	if wrapped.IsFatal() != cause.IsFatal() {
+		t.Fatal("Wrap should preserve fatality")
+	}
+	if wrapped.RetCode() != cause.RetCode() {
+		t.Fatalf("RetCode() = %d, want %d", wrapped.RetCode(), cause.RetCode())
+	}
+	if wrapped.Error() != "transfer failed: insufficient funds" {
+		t.Fatalf("Error() = %q", wrapped.Error())
+	}
+
+	fatalCause := Escalate(errString("corrupt state"), "bad head")
+	wrappedFatal := Wrap(fatalCause, "while loading actor")
+	if !wrappedFatal.IsFatal() {
+		t.Fatal("Wrap should preserve a fatal cause's fatality")
+	}
+}
+
+func TestWrapNilIsNil(t *testing.T) {
+	if err := Wrap(nil, "msg"); err != nil {
+		t.Fatalf("Wrap(nil, ...) = %v, want nil", err)
+	}
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
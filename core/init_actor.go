@@ -0,0 +1,207 @@
+package core
+
+import (
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// InitActorAddress is the well-known address of the singleton InitActor.
+// It is the only actor allowed to assign ID addresses, and every actor
+// creation is routed through a Send to it.
+var InitActorAddress = types.NewMainnetAddress([]byte("init"))
+
+// InitActorCodeCid identifies InitActor's code, both on the actor
+// installed at InitActorAddress and as the key it is registered under
+// with the Invoker, so that a self-send into InitActorAddress actually
+// dispatches somewhere.
+var InitActorCodeCid = actorCodeCid("fil/1/init")
+
+func actorCodeCid(name string) cid.Cid {
+	sum, err := mh.Sum([]byte(name), mh.SHA2_256, -1)
+	if err != nil {
+		panic(err)
+	}
+	return cid.NewCidV1(cid.Raw, sum)
+}
+
+func init() {
+	cbor.RegisterCborType(InitActorState{})
+	cbor.RegisterCborType(ExecParams{})
+	defaultInvoker.Register(InitActorCodeCid, InitActor{})
+}
+
+// Method numbers for the InitActor, in Exports() order.
+const (
+	// MethodInitExec is InitActor's actor-creation method.
+	MethodInitExec = 1
+)
+
+// InitActorState is the root state of the InitActor: a map from every
+// "robust" address (a hash-derived or public-key address) an actor was
+// created with onto the sequential ID address it was assigned, plus the
+// next ID to hand out. It is addressed, like any other actor's state,
+// through the actor's Head CID and exec.Storage.
+type InitActorState struct {
+	// AddressMap is the root of the robust-address -> ID-address
+	// mapping. It is a known stopgap: the map is currently a flat
+	// map[types.Address]types.Address (see addressMap below), fully
+	// loaded and fully re-serialized by loadAddressMap/flush on every
+	// Exec call, rather than the incremental HAMT this state is meant
+	// to eventually hold. Every actor creation goes through InitActor,
+	// so this does not scale the way the rest of VMContext's CID-
+	// addressed storage does. Replace with a real HAMT (e.g. via
+	// go-hamt-ipld) before this sees meaningful actor-creation volume.
+	AddressMap cid.Cid
+	NextID     uint64
+}
+
+// InitActor is the Invokee for the singleton InitActor.
+type InitActor struct{}
+
+// Exports returns InitActor's methods in method-number order. Method 0,
+// the constructor, is left nil and so cannot be invoked: InitActor's
+// state is installed directly at genesis rather than through a
+// constructor call.
+func (InitActor) Exports() []interface{} {
+	return []interface{}{
+		0: nil,
+		MethodInitExec: func(act *types.Actor, vmctx exec.VMContext, p *ExecParams) ([]byte, aerrors.ActorError) {
+			return initExec(act, vmctx, p)
+		},
+	}
+}
+
+// ExecParams are the parameters to InitActor's Exec method. RobustAddr
+// is always required: it is the hash-derived address the creator
+// computed for the new actor, recorded alongside the ID address so that
+// sends to either resolve to the same state-tree entry. Code and Params
+// are only set when the caller wants Exec to install the actor and run
+// its constructor in the same call; a bare address reservation (as used
+// by VMContext.AddressForNewActor) leaves them zero.
+type ExecParams struct {
+	RobustAddr types.Address
+	Code       cid.Cid
+	Params     []byte
+}
+
+// MarshalCBOR and UnmarshalCBOR are hand-written today; they become
+// generated code once cbor-gen is wired into the build for the
+// built-in actors.
+func (p *ExecParams) MarshalCBOR() ([]byte, error) {
+	return cbor.DumpObject(p)
+}
+
+func (p *ExecParams) UnmarshalCBOR(data []byte) (int, error) {
+	if err := cbor.DecodeInto(data, p); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// NewInitActor returns a freshly initialized, empty InitActor.
+func NewInitActor() *types.Actor {
+	return &types.Actor{Code: InitActorCodeCid}
+}
+
+// initExec assigns the next sequential ID address, records the mapping
+// from the caller-supplied robust address to that ID in the address
+// map, and -- if Code is set -- installs the new actor in the state
+// tree and, when Params is non-empty, invokes its constructor with
+// Params. It returns the new actor's ID address.
+func initExec(act *types.Actor, vmctx exec.VMContext, p *ExecParams) ([]byte, aerrors.ActorError) {
+	storage := vmctx.Storage()
+
+	state, aerr := loadInitState(storage, act.Head)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	idAddr := types.NewIDAddress(state.NextID)
+	state.NextID++
+
+	addressMap, aerr := loadAddressMap(storage, state.AddressMap)
+	if aerr != nil {
+		return nil, aerr
+	}
+	addressMap.set(p.RobustAddr, idAddr)
+	newMapRoot, aerr := addressMap.flush(storage)
+	if aerr != nil {
+		return nil, aerr
+	}
+	state.AddressMap = newMapRoot
+
+	newHead, aerr := storage.Put(state)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if aerr := storage.Commit(act.Head, newHead); aerr != nil {
+		return nil, aerr
+	}
+	act.Head = newHead
+
+	if p.Code.Defined() {
+		vmImpl, ok := vmctx.(*VMContext)
+		if !ok {
+			return nil, aerrors.New(1, "Exec must be invoked through a core.VMContext")
+		}
+		if err := vmImpl.setActor(idAddr, &types.Actor{Code: p.Code}); err != nil {
+			return nil, aerrors.Escalate(err, "failed to install newly created actor")
+		}
+		if len(p.Params) > 0 {
+			if _, aerr := vmctx.Send(idAddr, 0, nil, p.Params); aerr != nil {
+				return nil, aerrors.Wrap(aerr, "actor constructor failed")
+			}
+		}
+	}
+
+	return idAddr.Bytes(), nil
+}
+
+func loadInitState(storage exec.Storage, head cid.Cid) (*InitActorState, aerrors.ActorError) {
+	if !head.Defined() {
+		return &InitActorState{NextID: 1}, nil
+	}
+	state := &InitActorState{}
+	if aerr := storage.Get(head, state); aerr != nil {
+		return nil, aerr
+	}
+	return state, nil
+}
+
+// addressMap is the in-memory view of InitActorState.AddressMap used
+// while servicing a single Exec call. It is a plain map rather than an
+// incremental HAMT -- see the stopgap note on InitActorState.AddressMap
+// -- so loadAddressMap/flush always move the whole map through
+// exec.Storage rather than just the changed entries.
+type addressMap struct {
+	entries map[types.Address]types.Address
+}
+
+func loadAddressMap(storage exec.Storage, root cid.Cid) (*addressMap, aerrors.ActorError) {
+	m := &addressMap{entries: make(map[types.Address]types.Address)}
+	if !root.Defined() {
+		return m, nil
+	}
+	if aerr := storage.Get(root, &m.entries); aerr != nil {
+		return nil, aerr
+	}
+	return m, nil
+}
+
+func (m *addressMap) set(from, id types.Address) {
+	m.entries[from] = id
+}
+
+func (m *addressMap) flush(storage exec.Storage) (cid.Cid, aerrors.ActorError) {
+	return storage.Put(&m.entries)
+}
+
+func (m *addressMap) get(addr types.Address) (types.Address, bool) {
+	id, ok := m.entries[addr]
+	return id, ok
+}
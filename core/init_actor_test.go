@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestInitExecAssignsSequentialIDs(t *testing.T) {
+	store := newTestStore(t)
+	act := &types.Actor{}
+	vmctx := &VMContext{to: act, deps: &deps{Store: store}}
+
+	alice := types.NewAddress(1, []byte("alice"))
+	bob := types.NewAddress(1, []byte("bob"))
+
+	out1, aerr := initExec(act, vmctx, &ExecParams{RobustAddr: alice})
+	if aerr != nil {
+		t.Fatalf("initExec(alice) = %v, want nil", aerr)
+	}
+	out2, aerr := initExec(act, vmctx, &ExecParams{RobustAddr: bob})
+	if aerr != nil {
+		t.Fatalf("initExec(bob) = %v, want nil", aerr)
+	}
+
+	gotID1 := types.NewAddressFromBytes(out1)
+	gotID2 := types.NewAddressFromBytes(out2)
+	wantID1 := types.NewIDAddress(1)
+	wantID2 := types.NewIDAddress(2)
+
+	if gotID1 != wantID1 {
+		t.Fatalf("first id address = %v, want %v", gotID1, wantID1)
+	}
+	if gotID2 != wantID2 {
+		t.Fatalf("second id address = %v, want %v", gotID2, wantID2)
+	}
+}
+
+func TestInitExecRecordsAddressMapEntry(t *testing.T) {
+	store := newTestStore(t)
+	act := &types.Actor{}
+	vmctx := &VMContext{to: act, deps: &deps{Store: store}}
+
+	alice := types.NewAddress(1, []byte("alice"))
+	out, aerr := initExec(act, vmctx, &ExecParams{RobustAddr: alice})
+	if aerr != nil {
+		t.Fatalf("initExec(alice) = %v, want nil", aerr)
+	}
+	wantID := types.NewAddressFromBytes(out)
+
+	// Reload the state fresh from storage, the way a later, independent
+	// Exec call would, and confirm the mapping round-tripped.
+	storage := newActorStorage(context.Background(), store, act)
+	state, aerr := loadInitState(storage, act.Head)
+	if aerr != nil {
+		t.Fatalf("loadInitState() = %v, want nil", aerr)
+	}
+	addressMap, aerr := loadAddressMap(storage, state.AddressMap)
+	if aerr != nil {
+		t.Fatalf("loadAddressMap() = %v, want nil", aerr)
+	}
+	gotID, ok := addressMap.get(alice)
+	if !ok {
+		t.Fatal("expected alice to be registered in the address map")
+	}
+	if gotID != wantID {
+		t.Fatalf("addressMap.get(alice) = %v, want %v", gotID, wantID)
+	}
+}
+
+func TestResolveAddressPassesThroughIDAddresses(t *testing.T) {
+	ctx := &VMContext{}
+	id := types.NewIDAddress(5)
+
+	got, aerr := ctx.ResolveAddress(id)
+	if aerr != nil {
+		t.Fatalf("ResolveAddress(id) = %v, want nil", aerr)
+	}
+	if got != id {
+		t.Fatalf("ResolveAddress(id) = %v, want %v (unchanged)", got, id)
+	}
+}
+
+func TestResolveAddressReturnsRegisteredID(t *testing.T) {
+	store := newTestStore(t)
+	initActor := &types.Actor{}
+	initVMCtx := &VMContext{to: initActor, deps: &deps{Store: store}}
+
+	alice := types.NewAddress(1, []byte("alice"))
+	out, aerr := initExec(initActor, initVMCtx, &ExecParams{RobustAddr: alice})
+	if aerr != nil {
+		t.Fatalf("initExec(alice) = %v, want nil", aerr)
+	}
+	wantID := types.NewAddressFromBytes(out)
+
+	st := &fakeStateTree{actors: map[types.Address]*types.Actor{
+		InitActorAddress: initActor,
+	}}
+	ctx := &VMContext{state: st, deps: &deps{Store: store}}
+
+	gotID, aerr := ctx.ResolveAddress(alice)
+	if aerr != nil {
+		t.Fatalf("ResolveAddress(alice) = %v, want nil", aerr)
+	}
+	if gotID != wantID {
+		t.Fatalf("ResolveAddress(alice) = %v, want %v", gotID, wantID)
+	}
+}
+
+func TestResolveAddressErrorsOnUnregisteredAddress(t *testing.T) {
+	store := newTestStore(t)
+	initActor := &types.Actor{}
+	st := &fakeStateTree{actors: map[types.Address]*types.Actor{
+		InitActorAddress: initActor,
+	}}
+	ctx := &VMContext{state: st, deps: &deps{Store: store}}
+
+	_, aerr := ctx.ResolveAddress(types.NewAddress(1, []byte("nobody")))
+	if aerr == nil {
+		t.Fatal("ResolveAddress should error on an address the InitActor never assigned")
+	}
+	if aerr.IsFatal() {
+		t.Fatal("an unregistered address should be a revert, not a fatal error")
+	}
+}
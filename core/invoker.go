@@ -0,0 +1,171 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Invokee is implemented by every built-in actor's state type. Exports
+// returns the actor's methods in method-number order (method 0, the
+// constructor, first); a nil entry marks a method number as reserved
+// but not yet implemented. Each returned value must be a func matching
+// the shape `func(*types.Actor, exec.VMContext, *FooParams) ([]byte,
+// aerrors.ActorError)`, enforced by reflection when the actor is
+// registered with the Invoker.
+type Invokee interface {
+	Exports() []interface{}
+}
+
+// cborUnmarshaler is satisfied by every cbor-gen'd params type.
+type cborUnmarshaler interface {
+	UnmarshalCBOR([]byte) (int, error)
+}
+
+var (
+	errNotAFunc         = errors.New("export is not a func")
+	errWrongArgCount    = errors.New("export must take exactly 3 arguments")
+	errFirstArg         = errors.New("export's first argument must be *types.Actor")
+	errSecondArg        = errors.New("export's second argument must be exec.VMContext")
+	errThirdArg         = errors.New("export's third argument must be a pointer implementing UnmarshalCBOR")
+	errWrongReturnCount = errors.New("export must return exactly 2 values")
+	errFirstReturn      = errors.New("export's first return value must be []byte")
+	errSecondReturn     = errors.New("export's second return value must be aerrors.ActorError")
+
+	typeActorPtr  = reflect.TypeOf((*types.Actor)(nil))
+	typeVMContext = reflect.TypeOf((*exec.VMContext)(nil)).Elem()
+	typeBytes     = reflect.TypeOf([]byte(nil))
+	typeActorErr  = reflect.TypeOf((*aerrors.ActorError)(nil)).Elem()
+	typeUnmarshal = reflect.TypeOf((*cborUnmarshaler)(nil)).Elem()
+)
+
+// Invoker looks up and calls actor methods by method number, having
+// verified each one's signature by reflection at registration time
+// instead of trusting callers to hand-decode `[]interface{}` params.
+type Invoker struct {
+	methods map[cid.Cid][]reflect.Value
+}
+
+// NewInvoker returns an Invoker with no actors registered.
+func NewInvoker() *Invoker {
+	return &Invoker{methods: make(map[cid.Cid][]reflect.Value)}
+}
+
+// defaultInvoker is the Invoker used for in-VM dispatch that doesn't go
+// through the top-level core.Send entry point, such as a self-send.
+// Built-in actors register themselves into it from their own init.
+var defaultInvoker = NewInvoker()
+
+// Invoke dispatches method on act via defaultInvoker. It is threaded
+// through VMContext's deps as the default value of Invoke, so tests can
+// substitute a different invoker.
+func Invoke(act *types.Actor, vmctx exec.VMContext, method uint64, params []byte) ([]byte, aerrors.ActorError) {
+	return defaultInvoker.InvokeMethod(act, vmctx, method, params)
+}
+
+// Register validates and indexes every exported method of inv under
+// code, so that later calls to InvokeMethod can dispatch to it by
+// method number. It panics on a malformed Exports() entry: a bad
+// built-in actor is a programming error, not a runtime condition.
+func (iv *Invoker) Register(code cid.Cid, inv Invokee) {
+	fns := make([]reflect.Value, 0)
+	for i, export := range inv.Exports() {
+		if export == nil {
+			fns = append(fns, reflect.Value{})
+			continue
+		}
+		fnVal := reflect.ValueOf(export)
+		if err := validateExport(fnVal.Type()); err != nil {
+			panic(fmt.Sprintf("actor %s method %d: %s", code, i, err))
+		}
+		fns = append(fns, fnVal)
+	}
+	iv.methods[code] = fns
+}
+
+func validateExport(fnType reflect.Type) error {
+	if fnType.Kind() != reflect.Func {
+		return errNotAFunc
+	}
+	if fnType.NumIn() != 3 {
+		return errWrongArgCount
+	}
+	if fnType.In(0) != typeActorPtr {
+		return errFirstArg
+	}
+	if fnType.In(1) != typeVMContext {
+		return errSecondArg
+	}
+	if fnType.In(2).Kind() != reflect.Ptr || !fnType.In(2).Implements(typeUnmarshal) {
+		return errThirdArg
+	}
+	if fnType.NumOut() != 2 {
+		return errWrongReturnCount
+	}
+	if fnType.Out(0) != typeBytes {
+		return errFirstReturn
+	}
+	if fnType.Out(1) != typeActorErr {
+		return errSecondReturn
+	}
+	return nil
+}
+
+// InvokeMethod looks up method on the actor identified by act.Code and
+// calls it with act, vmctx, and params decoded by the method's params
+// type's UnmarshalCBOR. Empty params are not decoded: the method then
+// receives a zero-valued params object.
+func (iv *Invoker) InvokeMethod(act *types.Actor, vmctx exec.VMContext, method uint64, params []byte) ([]byte, aerrors.ActorError) {
+	fns, ok := iv.methods[act.Code]
+	if !ok {
+		return nil, aerrors.Newf(1, "unknown actor code %s", act.Code)
+	}
+	if method >= uint64(len(fns)) || !fns[method].IsValid() {
+		return nil, aerrors.Newf(1, "actor %s has no method %d", act.Code, method)
+	}
+	fn := fns[method]
+
+	paramT := fn.Type().In(2)
+	paramV := reflect.New(paramT.Elem())
+	if len(params) > 0 {
+		unmarshaler := paramV.Interface().(cborUnmarshaler)
+		if _, err := unmarshaler.UnmarshalCBOR(params); err != nil {
+			return nil, aerrors.Absorbf(err, 1, "failed to unmarshal params for method %d", method)
+		}
+	}
+
+	out := fn.Call([]reflect.Value{reflect.ValueOf(act), reflect.ValueOf(vmctx), paramV})
+	ret, _ := out[0].Interface().([]byte)
+	aerr, _ := out[1].Interface().(aerrors.ActorError)
+	return ret, aerr
+}
+
+// SerializeParams encodes obj with cbor-gen for use as actor method
+// params. obj must implement the cbor-gen MarshalCBOR method.
+func SerializeParams(obj cborMarshaler) ([]byte, aerrors.ActorError) {
+	buf, err := obj.MarshalCBOR()
+	if err != nil {
+		return nil, aerrors.Absorb(err, 1, "failed to marshal params")
+	}
+	return buf, nil
+}
+
+// DeserializeParams decodes data into out, which must implement the
+// cbor-gen UnmarshalCBOR method.
+func DeserializeParams(data []byte, out cborUnmarshaler) aerrors.ActorError {
+	if _, err := out.UnmarshalCBOR(data); err != nil {
+		return aerrors.Absorb(err, 1, "failed to unmarshal params")
+	}
+	return nil
+}
+
+// cborMarshaler is satisfied by every cbor-gen'd params type.
+type cborMarshaler interface {
+	MarshalCBOR() ([]byte, error)
+}
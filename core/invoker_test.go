@@ -0,0 +1,212 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// fakeVMContext is a no-op exec.VMContext, standing in wherever
+// InvokeMethod needs a concrete value to pass through to an export --
+// the exports under test here don't look at it.
+type fakeVMContext struct{}
+
+func (fakeVMContext) Message() *types.Message { return nil }
+func (fakeVMContext) Storage() exec.Storage   { return nil }
+func (fakeVMContext) Send(types.Address, uint64, *types.TokenAmount, []byte) ([]byte, aerrors.ActorError) {
+	return nil, nil
+}
+func (fakeVMContext) AddressForNewActor() (types.Address, aerrors.ActorError) {
+	return types.Address{}, nil
+}
+func (fakeVMContext) ReadStorage() []byte                    { return nil }
+func (fakeVMContext) WriteStorage([]byte) aerrors.ActorError { return nil }
+
+var _ exec.VMContext = fakeVMContext{}
+
+// fakeParams is a minimal cborUnmarshaler, standing in for a cbor-gen'd
+// params type in the signature checks below.
+type fakeParams struct{}
+
+func (*fakeParams) UnmarshalCBOR([]byte) (int, error) { return 0, nil }
+
+func validExport(*types.Actor, exec.VMContext, *fakeParams) ([]byte, aerrors.ActorError) {
+	return nil, nil
+}
+
+func fnType(fn interface{}) reflect.Type {
+	return reflect.TypeOf(fn)
+}
+
+func TestValidateExportAcceptsWellFormedMethod(t *testing.T) {
+	if err := validateExport(fnType(validExport)); err != nil {
+		t.Fatalf("validateExport() = %v, want nil", err)
+	}
+}
+
+func TestValidateExportRejectsNonFunc(t *testing.T) {
+	if err := validateExport(reflect.TypeOf(3)); err != errNotAFunc {
+		t.Fatalf("validateExport() = %v, want %v", err, errNotAFunc)
+	}
+}
+
+func TestValidateExportRejectsWrongArgCount(t *testing.T) {
+	bad := func(*types.Actor, exec.VMContext) ([]byte, aerrors.ActorError) { return nil, nil }
+	if err := validateExport(fnType(bad)); err != errWrongArgCount {
+		t.Fatalf("validateExport() = %v, want %v", err, errWrongArgCount)
+	}
+}
+
+func TestValidateExportRejectsWrongFirstArg(t *testing.T) {
+	bad := func(int, exec.VMContext, *fakeParams) ([]byte, aerrors.ActorError) { return nil, nil }
+	if err := validateExport(fnType(bad)); err != errFirstArg {
+		t.Fatalf("validateExport() = %v, want %v", err, errFirstArg)
+	}
+}
+
+func TestValidateExportRejectsWrongSecondArg(t *testing.T) {
+	bad := func(*types.Actor, int, *fakeParams) ([]byte, aerrors.ActorError) { return nil, nil }
+	if err := validateExport(fnType(bad)); err != errSecondArg {
+		t.Fatalf("validateExport() = %v, want %v", err, errSecondArg)
+	}
+}
+
+func TestValidateExportRejectsThirdArgNotPointer(t *testing.T) {
+	bad := func(*types.Actor, exec.VMContext, fakeParams) ([]byte, aerrors.ActorError) { return nil, nil }
+	if err := validateExport(fnType(bad)); err != errThirdArg {
+		t.Fatalf("validateExport() = %v, want %v", err, errThirdArg)
+	}
+}
+
+func TestValidateExportRejectsThirdArgNotUnmarshaler(t *testing.T) {
+	bad := func(*types.Actor, exec.VMContext, *int) ([]byte, aerrors.ActorError) { return nil, nil }
+	if err := validateExport(fnType(bad)); err != errThirdArg {
+		t.Fatalf("validateExport() = %v, want %v", err, errThirdArg)
+	}
+}
+
+func TestValidateExportRejectsWrongReturnCount(t *testing.T) {
+	bad := func(*types.Actor, exec.VMContext, *fakeParams) []byte { return nil }
+	if err := validateExport(fnType(bad)); err != errWrongReturnCount {
+		t.Fatalf("validateExport() = %v, want %v", err, errWrongReturnCount)
+	}
+}
+
+func TestValidateExportRejectsWrongFirstReturn(t *testing.T) {
+	bad := func(*types.Actor, exec.VMContext, *fakeParams) (string, aerrors.ActorError) { return "", nil }
+	if err := validateExport(fnType(bad)); err != errFirstReturn {
+		t.Fatalf("validateExport() = %v, want %v", err, errFirstReturn)
+	}
+}
+
+func TestValidateExportRejectsWrongSecondReturn(t *testing.T) {
+	bad := func(*types.Actor, exec.VMContext, *fakeParams) ([]byte, error) { return nil, nil }
+	if err := validateExport(fnType(bad)); err != errSecondReturn {
+		t.Fatalf("validateExport() = %v, want %v", err, errSecondReturn)
+	}
+}
+
+func TestRegisterPanicsOnMalformedExport(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register should panic on a malformed export")
+		}
+	}()
+	iv := NewInvoker()
+	iv.Register(actorCodeCid("fil/test/bad"), badInvokee{})
+}
+
+type badInvokee struct{}
+
+func (badInvokee) Exports() []interface{} {
+	return []interface{}{
+		0: func(int) {},
+	}
+}
+
+// strictParams only unmarshals successfully from the literal "valid",
+// so InvokeMethod's params-decoding failure path can be exercised
+// without a real cbor-gen type.
+type strictParams struct {
+	ok bool
+}
+
+func (p *strictParams) UnmarshalCBOR(data []byte) (int, error) {
+	if string(data) != "valid" {
+		return 0, errUnmarshalFailed
+	}
+	p.ok = true
+	return len(data), nil
+}
+
+var errUnmarshalFailed = errors.New("strictParams: invalid input")
+
+type knownInvokee struct{}
+
+func (knownInvokee) Exports() []interface{} {
+	return []interface{}{
+		0: nil, // constructor: reserved, not implemented
+		1: func(_ *types.Actor, _ exec.VMContext, _ *strictParams) ([]byte, aerrors.ActorError) {
+			return []byte("ok"), nil
+		},
+	}
+}
+
+func registerKnownInvokee(t *testing.T) (*Invoker, *types.Actor) {
+	t.Helper()
+	iv := NewInvoker()
+	code := actorCodeCid("fil/test/known")
+	iv.Register(code, knownInvokee{})
+	return iv, &types.Actor{Code: code}
+}
+
+func TestInvokeMethodUnknownActorCode(t *testing.T) {
+	iv := NewInvoker()
+	act := &types.Actor{Code: actorCodeCid("fil/test/never-registered")}
+
+	if _, aerr := iv.InvokeMethod(act, fakeVMContext{}, 1, nil); aerr == nil {
+		t.Fatal("InvokeMethod should error on an unregistered actor code")
+	}
+}
+
+func TestInvokeMethodOutOfRangeMethod(t *testing.T) {
+	iv, act := registerKnownInvokee(t)
+
+	if _, aerr := iv.InvokeMethod(act, fakeVMContext{}, 5, nil); aerr == nil {
+		t.Fatal("InvokeMethod should error on a method number past the end of Exports()")
+	}
+}
+
+func TestInvokeMethodNilExportIsNoMethod(t *testing.T) {
+	iv, act := registerKnownInvokee(t)
+
+	// Method 0 is registered as a nil placeholder (constructor, not yet
+	// implemented); it must not be dispatchable.
+	if _, aerr := iv.InvokeMethod(act, fakeVMContext{}, 0, nil); aerr == nil {
+		t.Fatal("InvokeMethod should error on a nil (reserved) export")
+	}
+}
+
+func TestInvokeMethodParamsUnmarshalFailure(t *testing.T) {
+	iv, act := registerKnownInvokee(t)
+
+	if _, aerr := iv.InvokeMethod(act, fakeVMContext{}, 1, []byte("garbage")); aerr == nil {
+		t.Fatal("InvokeMethod should propagate a params-unmarshal failure")
+	}
+}
+
+func TestInvokeMethodSuccessPropagatesReturnValue(t *testing.T) {
+	iv, act := registerKnownInvokee(t)
+
+	out, aerr := iv.InvokeMethod(act, fakeVMContext{}, 1, []byte("valid"))
+	if aerr != nil {
+		t.Fatalf("InvokeMethod() = %v, want nil", aerr)
+	}
+	if string(out) != "ok" {
+		t.Fatalf("InvokeMethod() = %q, want %q", out, "ok")
+	}
+}
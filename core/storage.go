@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// actorStorage is the per-invocation view of IPLD storage handed to an
+// actor through VMContext.Storage(). Actors serialize their root state
+// via cbor-gen into Put, then swap the actor's head to the returned CID
+// with Commit, which performs an optimistic-concurrency check against
+// the head the actor had when this view was created.
+type actorStorage struct {
+	ctx   context.Context
+	store cbor.IpldStore
+	actor *types.Actor
+	head  cid.Cid // head as observed when this storage view was created
+}
+
+var _ exec.Storage = (*actorStorage)(nil)
+
+func newActorStorage(ctx context.Context, store cbor.IpldStore, act *types.Actor) *actorStorage {
+	return &actorStorage{
+		ctx:   ctx,
+		store: store,
+		actor: act,
+		head:  act.Head,
+	}
+}
+
+// Put serializes obj with cbor-gen and writes it to the underlying
+// blockstore, returning its CID. It does not update the actor's head;
+// call Commit to do that once the new root has been computed.
+func (s *actorStorage) Put(obj interface{}) (cid.Cid, aerrors.ActorError) {
+	c, err := s.store.Put(s.ctx, obj)
+	if err != nil {
+		return cid.Undef, aerrors.Escalate(err, "failed to put object into actor storage")
+	}
+	return c, nil
+}
+
+// Get loads the object stored under c into out.
+func (s *actorStorage) Get(c cid.Cid, out interface{}) aerrors.ActorError {
+	if err := s.store.Get(s.ctx, c, out); err != nil {
+		return aerrors.Escalate(err, "failed to get object from actor storage")
+	}
+	return nil
+}
+
+// GetHead returns the actor's state root as observed when this storage
+// view was created.
+func (s *actorStorage) GetHead() cid.Cid {
+	return s.head
+}
+
+// Commit swaps the actor's head from oldHead to newHead, first checking
+// that oldHead still matches the head this view last observed (the head
+// it was created with, or the one its last successful Commit set). A
+// Commit from a head this view has already moved past is rejected.
+// Separate views of the same actor -- each call to VMContext.Storage()
+// creates one -- are not checked against one another.
+func (s *actorStorage) Commit(oldHead, newHead cid.Cid) aerrors.ActorError {
+	if !oldHead.Equals(s.head) {
+		return aerrors.New(1, "concurrent actor storage modification: stale head")
+	}
+	s.actor.Head = newHead
+	s.head = newHead
+	return nil
+}
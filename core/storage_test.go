@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func newTestStore(t *testing.T) cbor.IpldStore {
+	t.Helper()
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	return cbor.NewCborStore(bs)
+}
+
+func TestActorStorageCommitRejectsStaleHead(t *testing.T) {
+	act := &types.Actor{}
+	s := newActorStorage(context.Background(), newTestStore(t), act)
+
+	staleHead := testCid(t, "fil/test/stale-head")
+	newHead := testCid(t, "fil/test/new-head")
+
+	if aerr := s.Commit(staleHead, newHead); aerr == nil {
+		t.Fatal("Commit should reject an oldHead that doesn't match the observed head")
+	}
+	if act.Head.Defined() {
+		t.Fatal("a rejected Commit must not mutate the actor's head")
+	}
+}
+
+func TestActorStorageCommitAcceptsMatchingHead(t *testing.T) {
+	act := &types.Actor{}
+	s := newActorStorage(context.Background(), newTestStore(t), act)
+
+	newHead := testCid(t, "fil/test/new-head")
+	if aerr := s.Commit(s.GetHead(), newHead); aerr != nil {
+		t.Fatalf("Commit() = %v, want nil", aerr)
+	}
+	if act.Head != newHead {
+		t.Fatalf("actor Head = %s, want %s", act.Head, newHead)
+	}
+	if s.GetHead() != newHead {
+		t.Fatalf("GetHead() = %s, want %s", s.GetHead(), newHead)
+	}
+}
+
+// fakeStateTree is a minimal state.Tree that records the SetActor call
+// WriteStorage makes and serves GetActor from actors; its other methods
+// are unused by the paths under test here.
+type fakeStateTree struct {
+	setActorCalls int
+	lastAddr      types.Address
+	lastActor     *types.Actor
+
+	// actors backs GetActor for tests that need it (e.g. ResolveAddress
+	// loading the InitActor); unset for tests that don't.
+	actors map[types.Address]*types.Actor
+}
+
+func (f *fakeStateTree) SetActor(_ context.Context, addr types.Address, act *types.Actor) error {
+	f.setActorCalls++
+	f.lastAddr = addr
+	f.lastActor = act
+	return nil
+}
+
+func (f *fakeStateTree) GetActor(_ context.Context, addr types.Address) (*types.Actor, error) {
+	act, ok := f.actors[addr]
+	if !ok {
+		return nil, fmt.Errorf("no such actor: %s", addr)
+	}
+	return act, nil
+}
+
+func (f *fakeStateTree) GetOrCreateActor(context.Context, types.Address, func() (*types.Actor, error)) (*types.Actor, error) {
+	return nil, nil
+}
+
+func (f *fakeStateTree) IpldStore() cbor.IpldStore { return nil }
+
+func (f *fakeStateTree) Flush(context.Context) (cid.Cid, error) { return cid.Undef, nil }
+
+var _ state.Tree = (*fakeStateTree)(nil)
+
+func TestWriteStorageReadStorageRoundTrip(t *testing.T) {
+	addr := types.NewIDAddress(1)
+	msg := types.NewMessage(addr, addr, 0, nil, 0, nil)
+	st := &fakeStateTree{}
+
+	ctx := &VMContext{
+		to:      &types.Actor{},
+		message: msg,
+		state:   st,
+		deps:    &deps{Store: newTestStore(t)},
+	}
+
+	want := []byte("hello actor state")
+	if aerr := ctx.WriteStorage(want); aerr != nil {
+		t.Fatalf("WriteStorage() = %v, want nil", aerr)
+	}
+	if st.setActorCalls != 1 {
+		t.Fatalf("SetActor called %d times, want 1", st.setActorCalls)
+	}
+	if st.lastAddr != addr {
+		t.Fatalf("SetActor addr = %v, want %v", st.lastAddr, addr)
+	}
+
+	got := ctx.ReadStorage()
+	if string(got) != string(want) {
+		t.Fatalf("ReadStorage() = %q, want %q", got, want)
+	}
+}
+
+func TestReadStorageOnActorWithNoHeadReturnsNil(t *testing.T) {
+	ctx := &VMContext{to: &types.Actor{}}
+	if got := ctx.ReadStorage(); got != nil {
+		t.Fatalf("ReadStorage() on an actor with no head = %q, want nil", got)
+	}
+}
@@ -5,12 +5,31 @@ import (
 	"context"
 	"encoding/binary"
 
-	"github.com/filecoin-project/go-filecoin/abi"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+// rawStorageWrapper is the shape single-bytes-blob actors are (de)serialized
+// into under the legacy ReadStorage/WriteStorage API, kept as a thin shim
+// over the CID-addressed Storage() so that not-yet-migrated actors keep
+// working unchanged.
+type rawStorageWrapper struct {
+	Bytes []byte
+}
+
+func init() {
+	cbor.RegisterCborType(rawStorageWrapper{})
+}
+
+// maxReentrancyDepth bounds how many times a self-send may recurse
+// within a single top-level message, so that a buggy or malicious actor
+// calling back into itself can't blow the call stack.
+const maxReentrancyDepth = 4096
+
 // VMContext is the only thing exposed to an actor while executing.
 // All methods on the VMContext are ABI methods exposed to actors.
 type VMContext struct {
@@ -19,6 +38,15 @@ type VMContext struct {
 	message *types.Message
 	state   state.Tree
 
+	// caller is the address that, ultimately, originated this call
+	// chain. It is set once from the top-level message's From and
+	// carried unchanged through self-sends, where message.From is
+	// rewritten to the callee's own address and so can no longer tell
+	// the callee who is really invoking it.
+	caller types.Address
+	// depth counts nested self-sends within this call chain.
+	depth int
+
 	deps *deps // Inject external dependencies so we can unit test robustly.
 }
 
@@ -29,6 +57,7 @@ func NewVMContext(from, to *types.Actor, msg *types.Message, st state.Tree) *VMC
 		to:      to,
 		message: msg,
 		state:   st,
+		caller:  msg.From,
 		deps:    makeDeps(st),
 	}
 }
@@ -40,79 +69,232 @@ func (ctx *VMContext) Message() *types.Message {
 	return ctx.message
 }
 
-// ReadStorage reads the storage from the associated to actor.
+// Caller returns the address that ultimately originated this call
+// chain. Unlike Message().From, it survives self-sends: an actor that
+// has called back into itself still sees the real external caller here,
+// rather than its own address.
+func (ctx *VMContext) Caller() types.Address {
+	return ctx.caller
+}
+
+// Storage returns the CID-addressed IPLD storage actors use to persist
+// their own state. Large actors (storage market, miner) serialize a
+// root object via cbor-gen into Put and swap it in with Commit; this
+// lets them keep HAMT/AMT sub-structures instead of diffing a single
+// flat byte blob on every call.
+func (ctx *VMContext) Storage() exec.Storage {
+	return newActorStorage(context.Background(), ctx.deps.Store, ctx.to)
+}
+
+// ReadStorage reads the storage from the associated to actor. It is a
+// thin shim over Storage() for actors that have not yet been migrated
+// to the CID-addressed API: it Gets a single bytes object at the
+// actor's head.
 func (ctx *VMContext) ReadStorage() []byte {
-	return ctx.to.ReadStorage()
+	if !ctx.to.Head.Defined() {
+		return nil
+	}
+	var wrapper rawStorageWrapper
+	if err := ctx.Storage().Get(ctx.to.Head, &wrapper); err != nil {
+		return nil
+	}
+	return wrapper.Bytes
 }
 
-// WriteStorage writes to the storage of the associated to actor.
-func (ctx *VMContext) WriteStorage(memory []byte) error {
-	ctx.to.WriteStorage(memory)
-	return ctx.state.SetActor(context.Background(), ctx.message.To, ctx.to)
+// WriteStorage writes to the storage of the associated to actor. It is
+// a thin shim over Storage() for actors that have not yet been migrated
+// to the CID-addressed API: it Puts a single bytes object and Commits
+// it as the actor's new head, then flushes the actor to the state tree.
+func (ctx *VMContext) WriteStorage(memory []byte) aerrors.ActorError {
+	storage := ctx.Storage()
+	oldHead := storage.GetHead()
+
+	newHead, aerr := storage.Put(&rawStorageWrapper{Bytes: memory})
+	if aerr != nil {
+		return aerr
+	}
+	if aerr := storage.Commit(oldHead, newHead); aerr != nil {
+		return aerr
+	}
+
+	err := ctx.state.SetActor(context.Background(), ctx.message.To, ctx.to)
+	if err != nil {
+		// A failure to persist actor state is a problem with this node,
+		// not with the message being applied: abort the whole block.
+		return aerrors.Escalate(err, "failed to persist actor after WriteStorage")
+	}
+	return nil
 }
 
-// Send sends a message to another actor.
+// Send sends a message to another actor. method is a method number, and
+// params its raw CBOR encoding, as produced by SerializeParams -- the
+// Invoker decodes them into the target method's typed params before
+// calling it. This is the path built-in actors use; the abi.Value
+// encoding remains available for user contracts via a higher layer.
 // This method assumes to be called from inside the `to` actor.
-func (ctx *VMContext) Send(to types.Address, method string, value *types.TokenAmount, params []interface{}) ([]byte, uint8, error) {
+func (ctx *VMContext) Send(to types.Address, method uint64, value *types.TokenAmount, params []byte) ([]byte, aerrors.ActorError) {
 	deps := ctx.deps
 
 	// the message sender is the `to` actor, so this is what we set as `from` in the new message
 	from := ctx.Message().To
 	fromActor := ctx.to
 
-	vals, err := deps.ToValues(params)
-	if err != nil {
-		return nil, 1, faultErrorWrap(err, "failed to convert inputs to abi values")
-	}
-
-	paramData, err := deps.EncodeValues(vals)
-	if err != nil {
-		return nil, 1, revertErrorWrap(err, "encoding params failed")
+	// Normalize the destination to its ID address so that actors reached
+	// under different robust addresses still land on the same state-tree
+	// entry. InitActorAddress itself is exempt: it is where ID addresses
+	// come from in the first place.
+	resolvedTo := to
+	if to != InitActorAddress {
+		idAddr, aerr := ctx.ResolveAddress(to)
+		switch {
+		case aerr == nil:
+			resolvedTo = idAddr
+		case aerr.IsFatal():
+			// ResolveAddress couldn't even load the InitActor: that's a
+			// problem with this node's state, not with to being unknown,
+			// and must abort processing rather than fall through to
+			// treating to as an unresolved robust address.
+			return nil, aerr
+		default:
+			// No id address has been registered for to yet (e.g. it has
+			// never been sent to before). Fall back to the robust address
+			// as given; it is the caller's job to have reserved one via
+			// AddressForNewActor if it expects to land on an existing
+			// actor.
+		}
 	}
 
-	msg := types.NewMessage(from, to, 0, value, method, paramData)
+	msg := types.NewMessage(from, resolvedTo, 0, value, method, params)
 	if msg.From == msg.To {
-		// TODO: handle this
-		return nil, 1, newFaultErrorf("unhandled: sending to self (%s)", msg.From)
+		return ctx.sendToSelf(method, value, params)
 	}
 
 	toActor, err := deps.GetOrCreateActor(context.TODO(), msg.To, func() (*types.Actor, error) {
 		return NewAccountActor(nil)
 	})
 	if err != nil {
-		return nil, 1, faultErrorWrapf(err, "failed to get or create To actor %s", msg.To)
+		return nil, aerrors.Escalatef(err, "failed to get or create To actor %s", msg.To)
 	}
 	// TODO(fritz) de-dup some of the logic between here and core.Send
-	out, ret, err := deps.Send(context.Background(), fromActor, toActor, msg, ctx.state)
+	out, aerr := deps.Send(context.Background(), fromActor, toActor, msg, ctx.state)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	return out, nil
+}
+
+// sendToSelf services a Send where the calling actor is also the
+// target, such as a multisig actor proposing an internal transaction or
+// a miner actor re-entering to update its own sub-state. Rather than
+// fetching a second, independent snapshot of the same actor from the
+// state tree, it reuses ctx.to for both sides of the call so the nested
+// invocation sees every mutation made so far in this call chain. Nonce
+// is not incremented: a self-send is not a new top-level message.
+func (ctx *VMContext) sendToSelf(method uint64, value *types.TokenAmount, params []byte) ([]byte, aerrors.ActorError) {
+	if ctx.depth >= maxReentrancyDepth {
+		return nil, aerrors.Newf(1, "exceeded max reentrancy depth (%d)", maxReentrancyDepth)
+	}
+
+	selfAddr := ctx.Message().To
+	msg := types.NewMessage(selfAddr, selfAddr, ctx.to.Nonce, value, method, params)
+
+	nested := &VMContext{
+		from:    ctx.to,
+		to:      ctx.to,
+		message: msg,
+		state:   ctx.state,
+		caller:  ctx.caller,
+		depth:   ctx.depth + 1,
+		deps:    ctx.deps,
+	}
+
+	// A failed nested call must not leave partial storage mutations
+	// visible: remember the head so it can be rolled back.
+	oldHead := ctx.to.Head
+	out, aerr := ctx.deps.Invoke(ctx.to, nested, method, params)
+	if aerr != nil {
+		ctx.to.Head = oldHead
+		return nil, aerr
+	}
+	return out, nil
+}
+
+// AddressForNewActor computes the robust (hash-derived) address for a
+// new actor being created by the current invocation, the same way
+// Ethereum does, and reserves a matching ID address for it via the
+// InitActor so that later sends to either address resolve to the same
+// state-tree entry.
+func (ctx *VMContext) AddressForNewActor() (types.Address, aerrors.ActorError) {
+	robustAddr, aerr := computeActorAddress(ctx.message.From, ctx.from.Nonce)
+	if aerr != nil {
+		return types.Address{}, aerr
+	}
+
+	params, aerr := SerializeParams(&ExecParams{RobustAddr: robustAddr})
+	if aerr != nil {
+		return types.Address{}, aerr
+	}
+
+	out, aerr := ctx.Send(InitActorAddress, MethodInitExec, nil, params)
+	if aerr != nil {
+		return types.Address{}, aerrors.Wrap(aerr, "failed to reserve id address for new actor")
+	}
+	return types.NewAddressFromBytes(out), nil
+}
+
+// ResolveAddress normalizes addr to the ID address the InitActor
+// assigned it, so that actors can compare addresses reached under
+// different robust forms. addr is returned unchanged if it is already
+// an ID address.
+func (ctx *VMContext) ResolveAddress(addr types.Address) (types.Address, aerrors.ActorError) {
+	if addr.Protocol() == types.IDAddressProtocol {
+		return addr, nil
+	}
+
+	initActor, err := ctx.state.GetActor(context.Background(), InitActorAddress)
 	if err != nil {
-		return nil, ret, err
+		return types.Address{}, aerrors.Escalate(err, "failed to load InitActor")
+	}
+
+	initStorage := newActorStorage(context.Background(), ctx.deps.Store, initActor)
+	initState, aerr := loadInitState(initStorage, initActor.Head)
+	if aerr != nil {
+		return types.Address{}, aerr
 	}
 
-	return out, ret, nil
+	addressMap, aerr := loadAddressMap(initStorage, initState.AddressMap)
+	if aerr != nil {
+		return types.Address{}, aerr
+	}
+	idAddr, ok := addressMap.get(addr)
+	if !ok {
+		return types.Address{}, aerrors.Newf(1, "no id address registered for %s", addr)
+	}
+	return idAddr, nil
 }
 
-// AddressForNewActor creates computes the address for a new actor in the same
-// way that ethereum does.  Note that this will not work if we allow the
-// creation of multiple contracts in a given invocation (nonce will remain the
-// same, resulting in the same address back)
-func (ctx *VMContext) AddressForNewActor() (types.Address, error) {
-	return computeActorAddress(ctx.message.From, ctx.from.Nonce)
+// setActor installs act at addr in the state tree. It is unexported
+// because only VM-internal code (the InitActor, in particular) is
+// allowed to install actors directly; ordinary actors go through Send.
+func (ctx *VMContext) setActor(addr types.Address, act *types.Actor) error {
+	return ctx.state.SetActor(context.Background(), addr, act)
 }
 
-func computeActorAddress(creator types.Address, nonce uint64) (types.Address, error) {
+func computeActorAddress(creator types.Address, nonce uint64) (types.Address, aerrors.ActorError) {
 	buf := new(bytes.Buffer)
 
 	if _, err := buf.Write(creator.Bytes()); err != nil {
-		return types.Address{}, err
+		return types.Address{}, aerrors.Escalate(err, "failed to write creator address")
 	}
 
 	if err := binary.Write(buf, binary.BigEndian, nonce); err != nil {
-		return types.Address{}, err
+		return types.Address{}, aerrors.Escalate(err, "failed to write creator nonce")
 	}
 
 	hash, err := types.AddressHash(buf.Bytes())
 	if err != nil {
-		return types.Address{}, err
+		return types.Address{}, aerrors.Escalate(err, "failed to hash new actor address")
 	}
 
 	return types.NewMainnetAddress(hash), nil
@@ -123,21 +305,21 @@ func computeActorAddress(creator types.Address, nonce uint64) (types.Address, er
 // makeDeps returns a VMContext's external dependencies with their standard values set.
 func makeDeps(st state.Tree) *deps {
 	deps := deps{
-		EncodeValues: abi.EncodeValues,
-		Send:         Send,
-		ToValues:     abi.ToValues,
+		Invoke: Invoke,
+		Send:   Send,
 	}
 	if st != nil {
 		deps.SetActor = st.SetActor
 		deps.GetOrCreateActor = st.GetOrCreateActor
+		deps.Store = st.IpldStore()
 	}
 	return &deps
 }
 
 type deps struct {
-	EncodeValues     func([]*abi.Value) ([]byte, error)
 	GetOrCreateActor func(context.Context, types.Address, func() (*types.Actor, error)) (*types.Actor, error)
-	Send             func(context.Context, *types.Actor, *types.Actor, *types.Message, state.Tree) ([]byte, uint8, error)
+	Invoke           func(*types.Actor, exec.VMContext, uint64, []byte) ([]byte, aerrors.ActorError)
+	Send             func(context.Context, *types.Actor, *types.Actor, *types.Message, state.Tree) ([]byte, aerrors.ActorError)
 	SetActor         func(context.Context, types.Address, *types.Actor) error
-	ToValues         func([]interface{}) ([]*abi.Value, error)
+	Store            cbor.IpldStore
 }
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func testCid(t *testing.T, data string) cid.Cid {
+	t.Helper()
+	return actorCodeCid(data)
+}
+
+func TestSendToSelfRejectsExcessiveDepth(t *testing.T) {
+	selfAddr := types.NewIDAddress(1)
+	msg := types.NewMessage(selfAddr, selfAddr, 0, nil, 0, nil)
+	to := &types.Actor{Head: testCid(t, "fil/test/head")}
+
+	ctx := &VMContext{
+		to:      to,
+		message: msg,
+		caller:  selfAddr,
+		depth:   maxReentrancyDepth,
+		deps: &deps{
+			Invoke: func(*types.Actor, exec.VMContext, uint64, []byte) ([]byte, aerrors.ActorError) {
+				t.Fatal("Invoke should not be called once the depth limit is exceeded")
+				return nil, nil
+			},
+		},
+	}
+
+	_, aerr := ctx.sendToSelf(1, nil, nil)
+	if aerr == nil {
+		t.Fatal("sendToSelf should reject a call past maxReentrancyDepth")
+	}
+}
+
+func TestSendToSelfRollsBackHeadOnError(t *testing.T) {
+	selfAddr := types.NewIDAddress(1)
+	msg := types.NewMessage(selfAddr, selfAddr, 0, nil, 0, nil)
+	oldHead := testCid(t, "fil/test/old-head")
+	newHead := testCid(t, "fil/test/new-head")
+	to := &types.Actor{Head: oldHead}
+
+	wantErr := aerrors.New(1, "nested call failed")
+	ctx := &VMContext{
+		to:      to,
+		message: msg,
+		caller:  selfAddr,
+		deps: &deps{
+			Invoke: func(act *types.Actor, _ exec.VMContext, _ uint64, _ []byte) ([]byte, aerrors.ActorError) {
+				// Simulate the nested call mutating shared state before failing.
+				act.Head = newHead
+				return nil, wantErr
+			},
+		},
+	}
+
+	_, aerr := ctx.sendToSelf(1, nil, nil)
+	if aerr != wantErr {
+		t.Fatalf("sendToSelf() error = %v, want %v", aerr, wantErr)
+	}
+	if ctx.to.Head != oldHead {
+		t.Fatalf("Head = %s, want rollback to %s", ctx.to.Head, oldHead)
+	}
+}
+
+func TestSendToSelfPropagatesSuccessfulResult(t *testing.T) {
+	selfAddr := types.NewIDAddress(1)
+	msg := types.NewMessage(selfAddr, selfAddr, 0, nil, 0, nil)
+	to := &types.Actor{Head: testCid(t, "fil/test/head")}
+	want := []byte("ok")
+
+	ctx := &VMContext{
+		to:      to,
+		message: msg,
+		caller:  selfAddr,
+		deps: &deps{
+			Invoke: func(*types.Actor, exec.VMContext, uint64, []byte) ([]byte, aerrors.ActorError) {
+				return want, nil
+			},
+		},
+	}
+
+	out, aerr := ctx.sendToSelf(1, nil, nil)
+	if aerr != nil {
+		t.Fatalf("sendToSelf() error = %v, want nil", aerr)
+	}
+	if string(out) != string(want) {
+		t.Fatalf("sendToSelf() = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,37 @@
+// Package exec defines the interfaces an actor implementation is built
+// against: the VMContext it is handed on invocation, and the Storage it
+// uses to persist its own state.
+package exec
+
+import (
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/aerrors"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// VMContext is the only thing exposed to an actor while executing.
+// All methods on the VMContext are ABI methods exposed to actors.
+type VMContext interface {
+	Message() *types.Message
+	Storage() Storage
+	Send(to types.Address, method uint64, value *types.TokenAmount, params []byte) ([]byte, aerrors.ActorError)
+	AddressForNewActor() (types.Address, aerrors.ActorError)
+
+	// ReadStorage and WriteStorage are retained for actors that have not
+	// yet been migrated to the CID-addressed Storage() interface.
+	ReadStorage() []byte
+	WriteStorage(memory []byte) aerrors.ActorError
+}
+
+// Storage defines the storage module exposed to actors. An actor's state
+// is addressed by CID: Put serializes an object and returns the CID of
+// its encoding, Get loads the object back out, and Commit atomically
+// swaps the actor's state root under an optimistic-concurrency check
+// against the root it was last read at.
+type Storage interface {
+	Put(interface{}) (cid.Cid, aerrors.ActorError)
+	Get(cid.Cid, interface{}) aerrors.ActorError
+	GetHead() cid.Cid
+	Commit(oldHead, newHead cid.Cid) aerrors.ActorError
+}
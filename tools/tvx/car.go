@@ -0,0 +1,20 @@
+package tvx
+
+import (
+	"os"
+
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	car "github.com/ipld/go-car"
+)
+
+// loadCARFile reads every block in the CAR at path into bs.
+func loadCARFile(bs bstore.Blockstore, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	_, err = car.LoadCar(bs, f)
+	return err
+}
@@ -0,0 +1,116 @@
+package tvx
+
+import (
+	"context"
+	"fmt"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Divergence describes the first point at which replaying a vector
+// produced something other than what the vector expected.
+type Divergence struct {
+	// MessageIndex is the index into Vector.Messages of the message
+	// that diverged, or -1 if the post-state root itself diverged
+	// after every message matched.
+	MessageIndex int
+	Expected     string
+	Actual       string
+}
+
+func (d *Divergence) String() string {
+	if d.MessageIndex < 0 {
+		return fmt.Sprintf("post-state root: expected %s, got %s", d.Expected, d.Actual)
+	}
+	return fmt.Sprintf("message %d: expected %s, got %s", d.MessageIndex, d.Expected, d.Actual)
+}
+
+// Driver loads a Vector's pre-state into an in-memory blockstore and
+// replays its messages against it, checking every receipt and the final
+// state root against what the vector recorded.
+type Driver struct {
+	bs    bstore.Blockstore
+	store cbor.IpldStore
+}
+
+// NewDriver returns a Driver over a fresh in-memory blockstore.
+func NewDriver() *Driver {
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	return &Driver{
+		bs:    bs,
+		store: cbor.NewCborStore(bs),
+	}
+}
+
+// LoadCAR reads every block in the CAR at path into the Driver's
+// blockstore.
+func (d *Driver) LoadCAR(path string) error {
+	return loadCARFile(d.bs, path)
+}
+
+// Run replays every message in v against v.PreStateRoot and returns the
+// first Divergence found, or nil if the vector reproduced exactly.
+func (d *Driver) Run(ctx context.Context, v *Vector) (*Divergence, error) {
+	st, err := state.LoadTree(ctx, d.store, v.PreStateRoot)
+	if err != nil {
+		return nil, fmt.Errorf("loading pre-state root %s: %w", v.PreStateRoot, err)
+	}
+
+	for i, msg := range v.Messages {
+		fromActor, err := st.GetOrCreateActor(ctx, msg.From, func() (*types.Actor, error) {
+			return core.NewAccountActor(nil)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loading from actor for message %d: %w", i, err)
+		}
+		toActor, err := st.GetOrCreateActor(ctx, msg.To, func() (*types.Actor, error) {
+			return core.NewAccountActor(nil)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loading to actor for message %d: %w", i, err)
+		}
+
+		out, aerr := core.Send(ctx, fromActor, toActor, msg, st)
+
+		want := v.Receipts[i]
+		gotExit := uint8(0)
+		if aerr != nil {
+			gotExit = aerr.RetCode()
+		}
+		if gotExit != want.ExitCode {
+			return &Divergence{
+				MessageIndex: i,
+				Expected:     fmt.Sprintf("exit %d", want.ExitCode),
+				Actual:       fmt.Sprintf("exit %d", gotExit),
+			}, nil
+		}
+		if gotExit == 0 && string(out) != string(want.ReturnValue) {
+			return &Divergence{
+				MessageIndex: i,
+				Expected:     fmt.Sprintf("return %x", want.ReturnValue),
+				Actual:       fmt.Sprintf("return %x", out),
+			}, nil
+		}
+	}
+
+	gotRoot, err := st.Flush(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("flushing post-state: %w", err)
+	}
+	if !gotRoot.Equals(v.PostStateRoot) {
+		return &Divergence{
+			MessageIndex: -1,
+			Expected:     v.PostStateRoot.String(),
+			Actual:       gotRoot.String(),
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,45 @@
+// Package tvx implements a deterministic test-vector format for the VM:
+// a vector pins a pre-state, a sequence of messages, and the exact
+// post-state and receipts applying those messages should produce, so
+// that regressions in the invoker, InitActor, or storage layer can be
+// caught with stable fixtures instead of a full running node.
+package tvx
+
+import (
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Vector is a single deterministic message-application test case.
+type Vector struct {
+	// CARPath is the path to a CAR file holding every IPLD block
+	// reachable from PreStateRoot, relative to the vector's own file.
+	CARPath string `json:"car"`
+
+	// PreStateRoot is the state tree root the vector's messages are
+	// applied against.
+	PreStateRoot cid.Cid `json:"preStateRoot"`
+
+	// Epoch is the chain epoch the messages are applied at.
+	Epoch uint64 `json:"epoch"`
+
+	// Messages are applied in order against PreStateRoot.
+	Messages []*types.Message `json:"messages"`
+
+	// PostStateRoot is the state tree root expected after applying
+	// every message in Messages.
+	PostStateRoot cid.Cid `json:"postStateRoot"`
+
+	// Receipts holds the expected receipt for each entry in Messages,
+	// in the same order.
+	Receipts []Receipt `json:"receipts"`
+}
+
+// Receipt is the portion of a message receipt a vector pins down. Gas
+// is not tracked here: the VM has no gas-metering model yet, so there
+// is nothing for a vector to pin a gas figure against.
+type Receipt struct {
+	ExitCode    uint8  `json:"exitCode"`
+	ReturnValue []byte `json:"return"`
+}
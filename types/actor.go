@@ -0,0 +1,26 @@
+package types
+
+import (
+	"github.com/ipfs/go-cid"
+)
+
+// Actor is the central abstraction of entities in the state tree.
+//
+// The Actor type is not used directly, but is instead the concrete
+// version of exec.VMContext's in-VM representation of an actor: a code
+// CID identifying the actor's behaviour, a nonce for message ordering,
+// a balance, and a Head CID pointing at the actor's own state, which
+// the actor addresses and mutates through exec.Storage.
+type Actor struct {
+	// Code is the CID of the actor's code object.
+	Code cid.Cid
+	// Head is the CID of the root of the actor's state tree, as stored
+	// through exec.Storage. An actor with no state has the zero value
+	// (cid.Undef).
+	Head cid.Cid
+	// Nonce is the nonce expected on the next message sent *from* this
+	// actor, used to prevent replay and ordering issues.
+	Nonce uint64
+	// Balance is the amount of FIL held by this actor.
+	Balance *TokenAmount
+}
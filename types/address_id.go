@@ -0,0 +1,43 @@
+package types
+
+import "encoding/binary"
+
+// IDAddressProtocol is the address protocol byte for an "ID address": a
+// short, sequentially assigned identifier handed out by the InitActor
+// to every actor as it is created, as opposed to the longer "robust"
+// address derived from a public key or from hashing the creator and
+// nonce. Actors normalize addresses to their ID form before comparing
+// them, since a single actor may otherwise be reachable under more than
+// one robust address.
+const IDAddressProtocol = 0x00
+
+// NewIDAddress creates the ID address for id: protocol byte
+// IDAddressProtocol followed by id encoded as an unsigned varint.
+func NewIDAddress(id uint64) Address {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, id)
+	return NewAddress(IDAddressProtocol, buf[:n])
+}
+
+// Protocol returns the address's leading protocol byte, e.g.
+// IDAddressProtocol.
+func (a Address) Protocol() byte {
+	b := a.Bytes()
+	if len(b) == 0 {
+		return 0
+	}
+	return b[0]
+}
+
+// NewAddressFromBytes reconstructs an Address from its wire encoding, as
+// produced by Address.Bytes(). It is the inverse of Bytes() and is used
+// wherever an address round-trips through a byte slice, such as an
+// actor method's raw return value. It returns the zero Address if b is
+// empty, mirroring Protocol()'s guard above rather than panicking on a
+// malformed or truncated input.
+func NewAddressFromBytes(b []byte) Address {
+	if len(b) == 0 {
+		return Address{}
+	}
+	return NewAddress(b[0], b[1:])
+}